@@ -3,76 +3,136 @@ package main
 
 import (
 	"encoding/json"
-	"errors"
 	"log"
 	"net/http"
 	"sync"
+	"time"
 )
 
 type API struct {
-	// Rooms is list of current rooms/sessions (Key = Room ID)
-	Rooms map[string]*Room
+	// Store persists rooms/sessions (Key = Room ID)
+	Store RoomStore
 	PlaceAPI
+
+	// Pretty indents JSON responses, for easier manual debugging.
+	Pretty bool
+
+	// instantRooms tracks the most recent unscheduled room created by each
+	// owner, so New can clean up an abandoned one instead of letting
+	// instant rooms pile up when a host keeps requesting fresh ones.
+	instantRooms   map[string]string
+	instantRoomsMu sync.Mutex
+
+	// subscribers holds the live SubscribeHandler channels for each room
+	// ID, on this instance. Kept on API rather than Room because
+	// RoomStore.Get isn't guaranteed to return the same *Room value twice
+	// (MongoRoomStore decodes a fresh one per call), so registration and
+	// broadcast have to agree on somewhere stable to look.
+	subscribers   map[string]map[chan []byte]struct{}
+	subscribersMu sync.Mutex
 }
 
 // Room is the room/session returned to the client
 type Room struct {
 	// ID of the Room
-	ID string `json:"id"`
+	ID string `json:"id" bson:"_id"`
 
 	// ID of the room creator
 	// Only returned in New() to remain secret
-	HostID string `json:"hostid,omitempty"`
+	HostID string `json:"hostid,omitempty" bson:"hostid"`
 
 	// List of voters
-	Voters []string `json:"voters,omitempty"`
+	Voters []string `json:"voters,omitempty" bson:"voters"`
+
+	// IDs granted co-host privileges by the host, via AddCoHostHandler.
+	// Secret like HostID, so never returned by Get().
+	CoHosts []string `json:"-" bson:"cohosts,omitempty"`
 
 	// List of choices
-	Choices []string `json:"choices,omitempty"`
+	Choices []string `json:"choices,omitempty" bson:"choices"`
 
 	// List of choices and their total number of votes
 	// Seperate from Choices so the number of votes remains secret
-	Votes map[string]int32 `json:"-"`
+	Votes map[string]int32 `json:"-" bson:"votes"`
+
+	// Ballots records each voter's raw vote, keyed by name, so a kicked
+	// voter's tally can be reversed.
+	Ballots map[string]string `json:"-" bson:"ballots"`
 
 	// The winning choice - when populated signals end of voting
-	Winner string `json:"winner,omitempty"`
+	Winner string `json:"winner,omitempty" bson:"winner,omitempty"`
+
+	// VotingMode selects how ballots are parsed and tallied. Set once at
+	// New() and fixed for the life of the room.
+	VotingMode VotingMode `json:"votingmode,omitempty" bson:"votingmode,omitempty"`
 
 	// Options for the Place API
 	// TODO: Set in New()
-	PlaceOptions `json:"-"`
+	PlaceOptions `json:"-" bson:"placeoptions"`
+
+	// CreatedAt is when the room was created.
+	CreatedAt time.Time `json:"createdat,omitempty" bson:"createdat"`
+
+	// ScheduledAt is when voting is allowed to start. Zero means the room
+	// is an instant room, votable immediately.
+	ScheduledAt time.Time `json:"scheduledat,omitempty" bson:"scheduledat,omitempty"`
+
+	// EndedAt is when End() (or the idle reaper) closed voting. Zero means
+	// the room is still open.
+	EndedAt time.Time `json:"endedat,omitempty" bson:"endedat,omitempty"`
+
+	// Version is bumped on every store Update and used as an optimistic
+	// concurrency token, so concurrent writes from different API
+	// instances don't clobber each other.
+	Version int `json:"-" bson:"version"`
+
+	// UpdatedAt is the last time this room was persisted by a RoomStore.
+	// Used by RoomStore.ListExpired to find idle rooms.
+	UpdatedAt time.Time `json:"-" bson:"updatedat"`
 
 	// Mutex used to ensure syncronization
-	sync.Mutex `json:"-"`
+	sync.Mutex `json:"-" bson:"-"`
 }
 
-var (
-	ErrorRoomNotFound = errors.New("Room not found")
-	ErrorRoomEnded    = errors.New("Room has ended")
-	ErrorUnauthorized = errors.New("Unauthorized host ID")
-)
-
-// NewAPI initializes a new API
-func NewAPI(placeAPI PlaceAPI) *API {
-	return &API{
-		Rooms:    make(map[string]*Room),
-		PlaceAPI: placeAPI,
+// NewAPI initializes a new API backed by store and starts its background
+// reaper, which auto-ends idle rooms and evicts old ended ones.
+func NewAPI(placeAPI PlaceAPI, store RoomStore) *API {
+	api := &API{
+		Store:        store,
+		PlaceAPI:     placeAPI,
+		instantRooms: make(map[string]string),
+		subscribers:  make(map[string]map[chan []byte]struct{}),
 	}
+	go api.reapLoop()
+	return api
 }
 
 // Get Session Handler
 func (api *API) GetHandler(res http.ResponseWriter, req *http.Request) {
 	id := req.URL.Query().Get("id")
 	room, err := api.Get(id)
-	api.sendJSON(res, room, err)
+	api.sendJSON(res, req, room, err)
 }
 
 // New Session Handler
 func (api *API) NewHandler(res http.ResponseWriter, req *http.Request) {
 	qp := req.URL.Query()
 	address := qp.Get("address")
+	owner := qp.Get("owner")
+	votingMode := VotingMode(qp.Get("voting_mode"))
+
+	var scheduledAt time.Time
+	if raw := qp.Get("scheduled_at"); raw != "" {
+		parsed, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			api.sendJSON(res, req, nil, ErrorInvalidScheduledAt)
+			return
+		}
+		scheduledAt = parsed
+	}
 
-	room, err := api.New(address)
-	api.sendJSON(res, room, err)
+	room, err := api.New(address, owner, scheduledAt, votingMode)
+	api.sendJSON(res, req, room, err)
 }
 
 // Vote Session Handler
@@ -83,7 +143,7 @@ func (api *API) VoteHandler(res http.ResponseWriter, req *http.Request) {
 	vote := qp.Get("vote")
 
 	err := api.Vote(id, name, vote)
-	api.sendJSON(res, nil, err)
+	api.sendJSON(res, req, nil, err)
 }
 
 // End Session Handler
@@ -93,26 +153,56 @@ func (api *API) EndHandler(res http.ResponseWriter, req *http.Request) {
 	hostid := qp.Get("hostid")
 
 	err := api.End(id, hostid)
-	api.sendJSON(res, nil, err)
+	api.sendJSON(res, req, nil, err)
 }
 
 // Get a room!
 func (api *API) Get(id string) (*Room, error) {
-	room, ok := api.Rooms[id]
-	if !ok {
-		return nil, ErrorRoomNotFound
+	room, err := api.Store.Get(id)
+	if err != nil {
+		return nil, err
 	}
 
-	// Clear private fields
-	room.HostID = ""
-	room.Votes = nil
-	return room, nil
+	room.Lock()
+	defer room.Unlock()
+	return room.sanitized(), nil
+}
+
+// sanitized returns a copy of the room with private fields (HostID, Votes)
+// cleared, safe to serve back to clients. Callers must hold room.Lock().
+func (room *Room) sanitized() *Room {
+	return &Room{
+		ID:           room.ID,
+		Voters:       room.Voters,
+		Choices:      room.Choices,
+		Winner:       room.Winner,
+		PlaceOptions: room.PlaceOptions,
+		CreatedAt:    room.CreatedAt,
+		ScheduledAt:  room.ScheduledAt,
+		EndedAt:      room.EndedAt,
+		VotingMode:   room.VotingMode,
+	}
 }
 
 // New creates a new room
 // The only method that returns HostID to keep it secret
-func (api *API) New(address string) (*Room, error) {
-	log.Printf("NEW address=%s\n", address)
+// owner, if given, identifies the host across requests so an abandoned
+// instant room they created earlier can be cleaned up. scheduledAt, if
+// non-zero, delays when voting is allowed to start. votingMode selects how
+// ballots are tallied in End(); an empty votingMode defaults to plurality.
+func (api *API) New(address string, owner string, scheduledAt time.Time, votingMode VotingMode) (*Room, error) {
+	log.Printf("NEW address=%s owner=%s\n", address, owner)
+
+	if votingMode == "" {
+		votingMode = VotingModePlurality
+	}
+	if !validVotingMode(votingMode) {
+		return nil, ErrorInvalidVotingMode
+	}
+
+	if owner != "" {
+		api.reclaimInstantRoom(owner)
+	}
 
 	// Create new rom
 	room := Room{
@@ -120,7 +210,11 @@ func (api *API) New(address string) (*Room, error) {
 		HostID:       generateID(11),
 		Choices:      []string{},
 		Votes:        make(map[string]int32),
+		Ballots:      make(map[string]string),
 		PlaceOptions: PlaceOptions{},
+		CreatedAt:    time.Now(),
+		ScheduledAt:  scheduledAt,
+		VotingMode:   votingMode,
 	}
 
 	// Populate Choices and Votes
@@ -131,7 +225,18 @@ func (api *API) New(address string) (*Room, error) {
 	}
 
 	// Add room
-	api.Rooms[room.ID] = &room
+	if err := api.Store.Create(&room); err != nil {
+		return nil, err
+	}
+
+	// Track this as the owner's latest instant room so a future New() call
+	// can reclaim it if it's never used.
+	if owner != "" && scheduledAt.IsZero() {
+		api.instantRoomsMu.Lock()
+		api.instantRooms[owner] = room.ID
+		api.instantRoomsMu.Unlock()
+	}
+
 	return &room, nil
 }
 
@@ -139,81 +244,169 @@ func (api *API) New(address string) (*Room, error) {
 func (api *API) Vote(id string, name string, vote string) error {
 	log.Printf("VOTE id=%s name=%s\n", id, name)
 
-	// Get room
-	room, ok := api.Rooms[id]
-	if !ok {
-		return ErrorRoomNotFound
-	}
-	room.Lock()
-	defer room.Unlock()
+	return api.withRoom(id, func(room *Room) error {
+		// Skip if room has already ended
+		if room.Winner != "" || !room.EndedAt.IsZero() {
+			return ErrorRoomEnded
+		}
 
-	// Skip if room has already ended
-	if room.Winner != "" {
-		return ErrorRoomEnded
-	}
+		now := time.Now()
+		if !room.ScheduledAt.IsZero() && now.Before(room.ScheduledAt) {
+			return ErrorRoomNotStarted
+		}
+
+		if now.Sub(room.idleSince()) > idleTTL {
+			return ErrorRoomExpired
+		}
 
-	// Add voter and vote
-	room.Voters = append(room.Voters, name)
-	room.Votes[vote]++
-	return nil
+		if err := validateBallot(room.VotingMode, vote, room.Choices); err != nil {
+			return err
+		}
+
+		// Add voter and vote
+		room.Voters = append(room.Voters, name)
+		room.Ballots[name] = vote
+		if room.VotingMode == VotingModePlurality {
+			room.Votes[vote]++
+		}
+		return nil
+	})
 }
 
 // End a voting session
 // Tally votes and deterimine winning place
-// Can only be used by the Host
+// Can only be used by the host or a cohost
 func (api *API) End(id string, hostid string) error {
 	log.Printf("END id=%s hostid=%s\n", id, hostid)
 
-	// Get room
-	room, ok := api.Rooms[id]
-	if !ok {
-		return ErrorRoomNotFound
-	}
-	room.Lock()
-	defer room.Unlock()
+	return api.withRoom(id, func(room *Room) error {
+		if !room.isAuthorized(hostid) {
+			return ErrorUnauthorized
+		}
+
+		api.finishRoom(room)
+		return nil
+	})
+}
 
-	// Verify host ID
-	if room.HostID != hostid {
-		return ErrorUnauthorized
+// isAuthorized reports whether actorID is the room's host or one of its
+// cohosts.
+func (room *Room) isAuthorized(actorID string) bool {
+	if actorID == room.HostID {
+		return true
+	}
+	for _, cohostID := range room.CoHosts {
+		if actorID == cohostID {
+			return true
+		}
 	}
+	return false
+}
 
-	// Determine winning category
-	max := int32(0)
-	var winner Category
-	for k, v := range room.Votes {
-		if v > max {
-			max = v
-			winner = Category(k)
+// finishRoom tallies ballots via the room's Tallier, sets Winner, and marks
+// the room ended. Used by both the host-triggered End() and the idle
+// reaper's auto-end. Callers must hold room.Lock().
+func (api *API) finishRoom(room *Room) {
+	ranked := tallierFor(room.VotingMode).Tally(room)
+
+	// Find a place to eat! Fall through to the next-ranked category if an
+	// earlier one has no place.
+	for _, category := range ranked {
+		place, err := api.PlaceAPI.Get(room.PlaceOptions, category)
+		if err == nil && place != "" {
+			room.Winner = string(place)
+			break
 		}
 	}
+	room.EndedAt = time.Now()
+}
+
+// maxUpdateRetries bounds how many times withRoom retries a store Update
+// that lost an optimistic-concurrency race before giving up.
+const maxUpdateRetries = 3
+
+// withRoom fetches room by id, runs mutate under the room's lock, broadcasts
+// the result to subscribers, and persists it via the store. Update is
+// retried against a fresh read if another instance wrote the room first.
+//
+// room.Lock() is held through Store.Update, not just mutate: MemoryRoomStore
+// hands back the same *Room pointer on every Get, so Update writes Version/
+// UpdatedAt directly on a struct other callers may have that same pointer
+// to and be locking independently. Releasing the lock before Update would
+// let those writes race the room's own mutex.
+func (api *API) withRoom(id string, mutate func(room *Room) error) error {
+	for attempt := 0; attempt < maxUpdateRetries; attempt++ {
+		room, err := api.Store.Get(id)
+		if err != nil {
+			return err
+		}
+
+		room.Lock()
+		err = mutate(room)
+		if err != nil {
+			room.Unlock()
+			return err
+		}
+		api.broadcast(room)
+		err = api.Store.Update(room)
+		room.Unlock()
 
-	// Find a place to eat!
-	// TODO: If a place is not found, try 2nd, 3rd, etc. winning category
-	place, _ := api.PlaceAPI.Get(room.PlaceOptions, winner)
-	room.Winner = string(place)
-	return nil
+		if err == nil {
+			return nil
+		}
+		if err != ErrorConcurrentUpdate {
+			return err
+		}
+	}
+	return ErrorConcurrentUpdate
 }
 
 // Send JSON result/error response back to client
-func (api *API) sendJSON(w http.ResponseWriter, room *Room, err error) {
+func (api *API) sendJSON(w http.ResponseWriter, req *http.Request, payload interface{}, err error) {
 	// Enable CORS
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Header().Set("Content-Type", "application/json")
 
+	// Echo the caller's request ID on every response, not just errors, so
+	// a request can be traced end-to-end regardless of outcome.
+	requestID := req.Header.Get("X-Request-ID")
+	if requestID != "" {
+		w.Header().Set("X-Request-ID", requestID)
+	}
+
 	if err != nil {
-		// Send Error JSON result
-		e := map[string]string{"error": err.Error()}
-		result, _ := json.Marshal(e)
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write(result)
-	} else if room != nil {
-		// Send Room result
-		result, _ := json.Marshal(room)
+		// Send structured error envelope with the right HTTP status
+		apiErr := asAPIError(err)
+		body, marshalErr := api.marshal(&errorResponse{
+			Error:     apiErr,
+			RequestID: requestID,
+		})
+		if marshalErr != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(apiErr.HTTPStatus)
+		w.Write(body)
+	} else if payload != nil {
+		// Send result
+		body, marshalErr := api.marshal(payload)
+		if marshalErr != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
 		w.WriteHeader(http.StatusOK)
-		w.Write(result)
+		w.Write(body)
 	} else {
 		// Send blank result
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte(""))
 	}
 }
+
+// marshal encodes v as JSON, indenting it when api.Pretty is set.
+func (api *API) marshal(v interface{}) ([]byte, error) {
+	if api.Pretty {
+		return json.MarshalIndent(v, "", "  ")
+	}
+	return json.Marshal(v)
+}
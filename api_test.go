@@ -0,0 +1,230 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// fakePlaceAPI is a minimal PlaceAPI test double. byCategory maps a category
+// to the place finishRoom should resolve it to; a missing entry simulates
+// PlaceAPI.Get finding nothing, so finishRoom falls through to the next
+// ranked category.
+type fakePlaceAPI struct {
+	categories []Category
+	byCategory map[Category]Category
+}
+
+func (f *fakePlaceAPI) Categories() []Category {
+	return f.categories
+}
+
+func (f *fakePlaceAPI) Get(opts PlaceOptions, category Category) (Category, error) {
+	place, ok := f.byCategory[category]
+	if !ok {
+		return "", ErrorRoomNotFound
+	}
+	return place, nil
+}
+
+// newTestAPI builds an API backed by a MemoryRoomStore without starting the
+// background reaper, so lifecycle edge cases can be driven deterministically.
+func newTestAPI(placeAPI PlaceAPI) *API {
+	return &API{
+		Store:        NewMemoryRoomStore(),
+		PlaceAPI:     placeAPI,
+		instantRooms: make(map[string]string),
+		subscribers:  make(map[string]map[chan []byte]struct{}),
+	}
+}
+
+func TestVoteRejectsBeforeScheduledAt(t *testing.T) {
+	api := newTestAPI(&fakePlaceAPI{categories: []Category{"pizza"}})
+
+	room, err := api.New("", "", time.Now().Add(time.Hour), VotingModePlurality)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	if err := api.Vote(room.ID, "alice", "pizza"); err != ErrorRoomNotStarted {
+		t.Fatalf("Vote() error = %v, want ErrorRoomNotStarted", err)
+	}
+}
+
+// TestVoteAllowsFirstVoteWellAfterIdleTTL is a regression test for a room
+// scheduled more than idleTTL after creation: the first Vote() after the
+// scheduled start must succeed, not bounce off the idle-expiry check.
+func TestVoteAllowsFirstVoteWellAfterIdleTTL(t *testing.T) {
+	api := newTestAPI(&fakePlaceAPI{categories: []Category{"pizza"}})
+
+	scheduledAt := time.Now().Add(idleTTL * 2)
+	room, err := api.New("", "", scheduledAt, VotingModePlurality)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	// Simulate the scheduled start having arrived without anything else
+	// touching the room, so UpdatedAt is still stuck at creation time.
+	stored, err := api.Store.Get(room.ID)
+	if err != nil {
+		t.Fatalf("Store.Get() error = %v", err)
+	}
+	stored.Lock()
+	stored.ScheduledAt = time.Now().Add(-time.Minute)
+	stored.UpdatedAt = time.Now().Add(-(idleTTL * 2))
+	stored.Unlock()
+
+	if err := api.Vote(room.ID, "alice", "pizza"); err != nil {
+		t.Fatalf("Vote() error = %v, want nil", err)
+	}
+}
+
+// TestAutoEndSkipsScheduledRoomNotYetIdle is a regression test for the
+// reaper: ListExpired flags a room as a candidate once its frozen
+// UpdatedAt is older than idleTTL, but a room scheduled further out than
+// idleTTL shouldn't be auto-ended the moment it opens.
+func TestAutoEndSkipsScheduledRoomNotYetIdle(t *testing.T) {
+	api := newTestAPI(&fakePlaceAPI{categories: []Category{"pizza"}})
+
+	room, err := api.New("", "", time.Now().Add(idleTTL*2), VotingModePlurality)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	stored, err := api.Store.Get(room.ID)
+	if err != nil {
+		t.Fatalf("Store.Get() error = %v", err)
+	}
+	stored.Lock()
+	// Scheduled start arrived a minute ago; UpdatedAt is still stuck at
+	// creation time, well past idleTTL on its own.
+	stored.ScheduledAt = time.Now().Add(-time.Minute)
+	stored.UpdatedAt = time.Now().Add(-(idleTTL * 2))
+	stored.Unlock()
+
+	api.autoEnd(stored)
+
+	ended, err := api.Get(room.ID)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if !ended.EndedAt.IsZero() {
+		t.Fatalf("EndedAt = %v, want zero (room shouldn't be auto-ended yet)", ended.EndedAt)
+	}
+}
+
+// TestAutoEndEndsRoomIdlePastScheduledAt is the flip side: once a room has
+// genuinely been open for longer than idleTTL since its scheduled start,
+// the reaper should end it.
+func TestAutoEndEndsRoomIdlePastScheduledAt(t *testing.T) {
+	api := newTestAPI(&fakePlaceAPI{categories: []Category{"pizza"}})
+
+	room, err := api.New("", "", time.Time{}, VotingModePlurality)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	stored, err := api.Store.Get(room.ID)
+	if err != nil {
+		t.Fatalf("Store.Get() error = %v", err)
+	}
+	stored.Lock()
+	stored.ScheduledAt = time.Now().Add(-(idleTTL * 2))
+	stored.UpdatedAt = time.Now().Add(-(idleTTL * 2))
+	stored.Unlock()
+
+	api.autoEnd(stored)
+
+	ended, err := api.Get(room.ID)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if ended.EndedAt.IsZero() {
+		t.Fatal("EndedAt is zero, want room to be auto-ended")
+	}
+}
+
+func TestVoteRejectsAfterIdleExpiry(t *testing.T) {
+	api := newTestAPI(&fakePlaceAPI{categories: []Category{"pizza"}})
+
+	room, err := api.New("", "", time.Time{}, VotingModePlurality)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	stored, err := api.Store.Get(room.ID)
+	if err != nil {
+		t.Fatalf("Store.Get() error = %v", err)
+	}
+	stored.Lock()
+	stored.UpdatedAt = time.Now().Add(-(idleTTL + time.Minute))
+	stored.Unlock()
+
+	if err := api.Vote(room.ID, "alice", "pizza"); err != ErrorRoomExpired {
+		t.Fatalf("Vote() error = %v, want ErrorRoomExpired", err)
+	}
+}
+
+// flakyStore wraps a RoomStore and fails the first Update for a given room
+// with ErrorConcurrentUpdate, so withRoom's retry loop can be exercised.
+type flakyStore struct {
+	RoomStore
+	failed map[string]bool
+}
+
+func (s *flakyStore) Update(room *Room) error {
+	if !s.failed[room.ID] {
+		s.failed[room.ID] = true
+		return ErrorConcurrentUpdate
+	}
+	return s.RoomStore.Update(room)
+}
+
+func TestWithRoomRetriesOnConcurrentUpdate(t *testing.T) {
+	api := newTestAPI(&fakePlaceAPI{categories: []Category{"pizza"}})
+	api.Store = &flakyStore{RoomStore: api.Store, failed: make(map[string]bool)}
+
+	room, err := api.New("", "", time.Time{}, VotingModePlurality)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	if err := api.Vote(room.ID, "alice", "pizza"); err != nil {
+		t.Fatalf("Vote() error = %v, want nil after retry", err)
+	}
+}
+
+func TestEndFallsThroughWhenPlaceMissing(t *testing.T) {
+	api := newTestAPI(&fakePlaceAPI{
+		categories: []Category{"pizza", "sushi"},
+		byCategory: map[Category]Category{
+			// pizza wins the tally but has no place; sushi does.
+			"sushi": "Sushi Palace",
+		},
+	})
+
+	room, err := api.New("", "", time.Time{}, VotingModePlurality)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if err := api.Vote(room.ID, "alice", "pizza"); err != nil {
+		t.Fatalf("Vote() error = %v", err)
+	}
+	if err := api.Vote(room.ID, "bob", "pizza"); err != nil {
+		t.Fatalf("Vote() error = %v", err)
+	}
+	if err := api.Vote(room.ID, "carol", "sushi"); err != nil {
+		t.Fatalf("Vote() error = %v", err)
+	}
+
+	if err := api.End(room.ID, room.HostID); err != nil {
+		t.Fatalf("End() error = %v", err)
+	}
+
+	ended, err := api.Get(room.ID)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if ended.Winner != "Sushi Palace" {
+		t.Fatalf("Winner = %q, want %q", ended.Winner, "Sushi Palace")
+	}
+}
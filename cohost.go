@@ -0,0 +1,132 @@
+// cohost.go lets a host delegate moderation duties: granting/revoking
+// cohost privileges and kicking a voter, which either the host or any of
+// its cohosts may do.
+package main
+
+import (
+	"log"
+	"net/http"
+)
+
+// CoHostGrant is returned by AddCoHostHandler with the newly minted cohost
+// ID. Like HostID, it's only ever handed out once, by the host that granted
+// it.
+type CoHostGrant struct {
+	CoHostID string `json:"cohostid"`
+}
+
+// Add CoHost Handler
+// Grants cohost privileges in a room. Only the host may call this.
+func (api *API) AddCoHostHandler(res http.ResponseWriter, req *http.Request) {
+	qp := req.URL.Query()
+	id := qp.Get("id")
+	hostid := qp.Get("hostid")
+
+	cohostID, err := api.AddCoHost(id, hostid)
+	if err != nil {
+		api.sendJSON(res, req, nil, err)
+		return
+	}
+	api.sendJSON(res, req, &CoHostGrant{CoHostID: cohostID}, nil)
+}
+
+// Remove CoHost Handler
+// Revokes cohost privileges in a room. Only the host may call this.
+func (api *API) RemoveCoHostHandler(res http.ResponseWriter, req *http.Request) {
+	qp := req.URL.Query()
+	id := qp.Get("id")
+	hostid := qp.Get("hostid")
+	cohostid := qp.Get("cohostid")
+
+	err := api.RemoveCoHost(id, hostid, cohostid)
+	api.sendJSON(res, req, nil, err)
+}
+
+// Kick Voter Handler
+// Removes a voter and their vote from a room. Callable by the host or any
+// of its cohosts.
+func (api *API) KickVoterHandler(res http.ResponseWriter, req *http.Request) {
+	qp := req.URL.Query()
+	id := qp.Get("id")
+	hostid := qp.Get("hostid")
+	name := qp.Get("name")
+
+	err := api.KickVoter(id, hostid, name)
+	api.sendJSON(res, req, nil, err)
+}
+
+// AddCoHost grants a newly generated cohost ID in room, returning it so the
+// host can share it. Can only be used by the host.
+func (api *API) AddCoHost(id string, hostid string) (string, error) {
+	log.Printf("ADDCOHOST id=%s\n", id)
+
+	var cohostID string
+	err := api.withRoom(id, func(room *Room) error {
+		if room.HostID != hostid {
+			return ErrorUnauthorized
+		}
+		cohostID = generateID(11)
+		room.CoHosts = append(room.CoHosts, cohostID)
+		return nil
+	})
+	return cohostID, err
+}
+
+// RemoveCoHost revokes cohostid's privileges in room. Can only be used by
+// the host.
+func (api *API) RemoveCoHost(id string, hostid string, cohostid string) error {
+	log.Printf("REMOVECOHOST id=%s\n", id)
+
+	return api.withRoom(id, func(room *Room) error {
+		if room.HostID != hostid {
+			return ErrorUnauthorized
+		}
+		room.CoHosts = removeString(room.CoHosts, cohostid)
+		return nil
+	})
+}
+
+// KickVoter removes name from room's voters and reverses their vote. Can be
+// used by the host or any of its cohosts.
+func (api *API) KickVoter(id string, actorid string, name string) error {
+	log.Printf("KICK id=%s name=%s\n", id, name)
+
+	return api.withRoom(id, func(room *Room) error {
+		if !room.isAuthorized(actorid) {
+			return ErrorUnauthorized
+		}
+
+		idx := indexOfString(room.Voters, name)
+		if idx == -1 {
+			return ErrorVoterNotFound
+		}
+		room.Voters = append(room.Voters[:idx], room.Voters[idx+1:]...)
+
+		if vote, ok := room.Ballots[name]; ok {
+			if room.VotingMode == VotingModePlurality {
+				room.Votes[vote]--
+			}
+			delete(room.Ballots, name)
+		}
+		return nil
+	})
+}
+
+// indexOfString returns the index of s in list, or -1 if not present.
+func indexOfString(list []string, s string) int {
+	for i, v := range list {
+		if v == s {
+			return i
+		}
+	}
+	return -1
+}
+
+// removeString returns list with the first occurrence of s removed.
+func removeString(list []string, s string) []string {
+	idx := indexOfString(list, s)
+	if idx == -1 {
+		return list
+	}
+	return append(list[:idx], list[idx+1:]...)
+}
@@ -0,0 +1,82 @@
+// errors.go defines APIError, the structured error every handler returns,
+// and the package's sentinel errors as concrete APIErrors so each one
+// carries the HTTP status and stable code sendJSON should use for it.
+package main
+
+import "net/http"
+
+// APIError is an error annotated with the HTTP status and stable code
+// clients can match on, instead of every failure mapping to a bare 500.
+type APIError struct {
+	Code       string `json:"code"`
+	HTTPStatus int    `json:"-"`
+	Message    string `json:"message"`
+}
+
+func (e *APIError) Error() string {
+	return e.Message
+}
+
+// asAPIError maps err to an APIError for sendJSON. Errors that aren't
+// already an *APIError (e.g. a RoomStore's underlying driver error) become
+// a generic 500 rather than leaking implementation details.
+func asAPIError(err error) *APIError {
+	if apiErr, ok := err.(*APIError); ok {
+		return apiErr
+	}
+	return &APIError{
+		Code:       "internal_error",
+		HTTPStatus: http.StatusInternalServerError,
+		Message:    "Internal server error",
+	}
+}
+
+// errorResponse is the envelope sendJSON emits for errors:
+// {"error":{"code":"...","message":"..."},"requestid":"..."}
+type errorResponse struct {
+	Error     *APIError `json:"error"`
+	RequestID string    `json:"requestid,omitempty"`
+}
+
+var (
+	ErrorRoomNotFound = &APIError{
+		Code: "room_not_found", HTTPStatus: http.StatusNotFound,
+		Message: "Room not found",
+	}
+	ErrorRoomEnded = &APIError{
+		Code: "room_ended", HTTPStatus: http.StatusConflict,
+		Message: "Room has ended",
+	}
+	ErrorUnauthorized = &APIError{
+		Code: "unauthorized", HTTPStatus: http.StatusForbidden,
+		Message: "Unauthorized host ID",
+	}
+	ErrorRoomNotStarted = &APIError{
+		Code: "room_not_started", HTTPStatus: http.StatusConflict,
+		Message: "Room has not started yet",
+	}
+	ErrorRoomExpired = &APIError{
+		Code: "room_expired", HTTPStatus: http.StatusGone,
+		Message: "Room has expired from inactivity",
+	}
+	ErrorInvalidScheduledAt = &APIError{
+		Code: "invalid_scheduled_at", HTTPStatus: http.StatusBadRequest,
+		Message: "Invalid scheduled_at",
+	}
+	ErrorVoterNotFound = &APIError{
+		Code: "voter_not_found", HTTPStatus: http.StatusNotFound,
+		Message: "Voter not found",
+	}
+	ErrorInvalidVotingMode = &APIError{
+		Code: "invalid_voting_mode", HTTPStatus: http.StatusBadRequest,
+		Message: "Invalid voting_mode",
+	}
+	ErrorInvalidVote = &APIError{
+		Code: "invalid_vote", HTTPStatus: http.StatusBadRequest,
+		Message: "Invalid vote",
+	}
+	ErrorConcurrentUpdate = &APIError{
+		Code: "concurrent_update", HTTPStatus: http.StatusConflict,
+		Message: "Room updated concurrently, retry",
+	}
+)
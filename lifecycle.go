@@ -0,0 +1,133 @@
+// lifecycle.go manages how long a Room stays around: the idle TTL that
+// closes abandoned open rooms, the retention window that evicts rooms long
+// after they've ended, and reclaiming a host's abandoned instant room when
+// they ask for a fresh one.
+package main
+
+import (
+	"log"
+	"time"
+)
+
+const (
+	// reapInterval is how often the background reaper scans for work.
+	reapInterval = time.Minute
+
+	// idleTTL is how long a room can go without a Vote/End before it's
+	// considered abandoned and auto-ended by the reaper.
+	idleTTL = 30 * time.Minute
+
+	// retentionWindow is how long an ended room is kept around (so the
+	// result stays fetchable) before the reaper evicts it.
+	retentionWindow = 24 * time.Hour
+)
+
+// idleSince returns the time from which room's idleness should be
+// measured: its last update, or its scheduled start if that's later. Used
+// by both Vote and autoEnd so a room scheduled further out than idleTTL
+// doesn't look idle-expired the moment it actually opens.
+func (room *Room) idleSince() time.Time {
+	since := room.UpdatedAt
+	if room.ScheduledAt.After(since) {
+		since = room.ScheduledAt
+	}
+	return since
+}
+
+// reapLoop periodically runs reap until the process exits.
+func (api *API) reapLoop() {
+	ticker := time.NewTicker(reapInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		api.reap()
+	}
+}
+
+// reap auto-ends rooms idle past idleTTL and evicts ended rooms older than
+// retentionWindow.
+func (api *API) reap() {
+	now := time.Now()
+
+	idle, err := api.Store.ListExpired(now.Add(-idleTTL))
+	if err != nil {
+		log.Printf("REAP idle scan error: %s\n", err)
+	}
+	for _, room := range idle {
+		api.autoEnd(room)
+	}
+
+	stale, err := api.Store.ListExpired(now.Add(-retentionWindow))
+	if err != nil {
+		log.Printf("REAP retention scan error: %s\n", err)
+		return
+	}
+	for _, room := range stale {
+		room.Lock()
+		ended := !room.EndedAt.IsZero()
+		room.Unlock()
+		if !ended {
+			continue
+		}
+		if err := api.Store.Delete(room.ID); err != nil {
+			log.Printf("REAP evict id=%s error: %s\n", room.ID, err)
+		}
+	}
+}
+
+// autoEnd closes voting on an idle room that was never explicitly Ended.
+// RoomStore.ListExpired only filters on UpdatedAt, which is frozen at Create
+// time until a room's first Vote, so a scheduled room must be re-checked
+// here against its own idle anchor before it's actually ended.
+func (api *API) autoEnd(room *Room) {
+	room.Lock()
+
+	if !room.EndedAt.IsZero() || room.Winner != "" {
+		room.Unlock()
+		return
+	}
+	if time.Now().Sub(room.idleSince()) <= idleTTL {
+		room.Unlock()
+		return
+	}
+
+	api.finishRoom(room)
+	api.broadcast(room)
+	// Held through Store.Update for the same reason as withRoom: a shared
+	// *Room pointer (MemoryRoomStore) must not have Version/UpdatedAt
+	// written outside its own mutex.
+	err := api.Store.Update(room)
+	room.Unlock()
+
+	if err != nil {
+		log.Printf("REAP auto-end id=%s error: %s\n", room.ID, err)
+	}
+}
+
+// reclaimInstantRoom deletes owner's previous instant (unscheduled) room if
+// it was never voted on, so repeatedly requesting a fresh room doesn't
+// leave a trail of abandoned ones behind.
+func (api *API) reclaimInstantRoom(owner string) {
+	api.instantRoomsMu.Lock()
+	prevID, ok := api.instantRooms[owner]
+	delete(api.instantRooms, owner)
+	api.instantRoomsMu.Unlock()
+	if !ok {
+		return
+	}
+
+	prev, err := api.Store.Get(prevID)
+	if err != nil {
+		return
+	}
+
+	prev.Lock()
+	unused := len(prev.Voters) == 0 && prev.Winner == ""
+	prev.Unlock()
+	if !unused {
+		return
+	}
+
+	if err := api.Store.Delete(prevID); err != nil {
+		log.Printf("NEW cleanup id=%s error: %s\n", prevID, err)
+	}
+}
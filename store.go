@@ -0,0 +1,135 @@
+// store.go defines the persistence layer for Rooms. API talks to rooms
+// exclusively through the RoomStore interface so the backing storage can be
+// swapped (in-memory for a single instance, MongoDB to share state and
+// survive restarts across instances) without touching handler code.
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// RoomStore persists and retrieves Rooms.
+type RoomStore interface {
+	// Create persists a brand new room.
+	Create(room *Room) error
+
+	// Get fetches a room by ID. Returns ErrorRoomNotFound if it doesn't exist.
+	Get(id string) (*Room, error)
+
+	// Update persists changes to an existing room. Implementations must use
+	// optimistic concurrency (keyed off Room.Version) so concurrent updates
+	// from different replicas don't clobber each other; a losing update
+	// returns ErrorConcurrentUpdate.
+	Update(room *Room) error
+
+	// Delete removes a room by ID.
+	Delete(id string) error
+
+	// ListExpired returns rooms last updated before cutoff, for the
+	// background reaper to End or evict.
+	ListExpired(cutoff time.Time) ([]*Room, error)
+}
+
+// StoreDriver selects which RoomStore backend NewStore builds.
+type StoreDriver string
+
+const (
+	// StoreDriverMemory keeps rooms in an in-process map. Default, and the
+	// only sensible choice for a single instance.
+	StoreDriverMemory StoreDriver = "memory"
+
+	// StoreDriverMongo persists rooms to MongoDB so they survive restarts
+	// and can be shared across instances.
+	StoreDriverMongo StoreDriver = "mongo"
+)
+
+// StoreConfig selects and configures a RoomStore.
+type StoreConfig struct {
+	Driver StoreDriver
+
+	// MongoURI and MongoDatabase are required when Driver is StoreDriverMongo.
+	MongoURI      string
+	MongoDatabase string
+}
+
+// NewStore builds the RoomStore described by cfg.
+func NewStore(cfg StoreConfig) (RoomStore, error) {
+	switch cfg.Driver {
+	case "", StoreDriverMemory:
+		return NewMemoryRoomStore(), nil
+	case StoreDriverMongo:
+		return NewMongoRoomStore(cfg.MongoURI, cfg.MongoDatabase)
+	default:
+		return nil, fmt.Errorf("sandwhich: unknown room store driver %q", cfg.Driver)
+	}
+}
+
+// MemoryRoomStore is the default RoomStore: an in-memory map that vanishes
+// on restart and isn't shared across instances.
+type MemoryRoomStore struct {
+	mu    sync.RWMutex
+	rooms map[string]*Room
+}
+
+// NewMemoryRoomStore initializes an empty MemoryRoomStore.
+func NewMemoryRoomStore() *MemoryRoomStore {
+	return &MemoryRoomStore{rooms: make(map[string]*Room)}
+}
+
+func (s *MemoryRoomStore) Create(room *Room) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	room.UpdatedAt = time.Now()
+	s.rooms[room.ID] = room
+	return nil
+}
+
+func (s *MemoryRoomStore) Get(id string) (*Room, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	room, ok := s.rooms[id]
+	if !ok {
+		return nil, ErrorRoomNotFound
+	}
+	return room, nil
+}
+
+func (s *MemoryRoomStore) Update(room *Room) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.rooms[room.ID]
+	if !ok {
+		return ErrorRoomNotFound
+	}
+	if existing.Version != room.Version {
+		return ErrorConcurrentUpdate
+	}
+
+	room.Version++
+	room.UpdatedAt = time.Now()
+	s.rooms[room.ID] = room
+	return nil
+}
+
+func (s *MemoryRoomStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.rooms, id)
+	return nil
+}
+
+func (s *MemoryRoomStore) ListExpired(cutoff time.Time) ([]*Room, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var expired []*Room
+	for _, room := range s.rooms {
+		if room.UpdatedAt.Before(cutoff) {
+			expired = append(expired, room)
+		}
+	}
+	return expired, nil
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// mongoTimeout bounds every request this store makes to Mongo.
+const mongoTimeout = 5 * time.Second
+
+// roomsCollection is the collection name rooms are stored under.
+const roomsCollection = "rooms"
+
+// MongoRoomStore persists rooms to a MongoDB collection, keyed by Room.ID,
+// so rooms survive restarts and can be shared across API instances.
+type MongoRoomStore struct {
+	collection *mongo.Collection
+}
+
+// NewMongoRoomStore connects to uri and returns a RoomStore backed by the
+// "rooms" collection of database.
+func NewMongoRoomStore(uri string, database string) (*MongoRoomStore, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+	defer cancel()
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		return nil, err
+	}
+	if err := client.Ping(ctx, nil); err != nil {
+		return nil, err
+	}
+
+	return &MongoRoomStore{
+		collection: client.Database(database).Collection(roomsCollection),
+	}, nil
+}
+
+func (s *MongoRoomStore) Create(room *Room) error {
+	ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+	defer cancel()
+
+	room.UpdatedAt = time.Now()
+	_, err := s.collection.InsertOne(ctx, room)
+	return err
+}
+
+func (s *MongoRoomStore) Get(id string) (*Room, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+	defer cancel()
+
+	var room Room
+	err := s.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&room)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrorRoomNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &room, nil
+}
+
+// Update persists room, using Version as an optimistic concurrency token:
+// the write only applies if Version still matches what's stored, otherwise
+// ErrorConcurrentUpdate is returned and the caller should re-Get and retry.
+func (s *MongoRoomStore) Update(room *Room) error {
+	ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+	defer cancel()
+
+	matchVersion := room.Version
+	room.Version++
+	room.UpdatedAt = time.Now()
+
+	result, err := s.collection.ReplaceOne(ctx,
+		bson.M{"_id": room.ID, "version": matchVersion},
+		room,
+	)
+	if err != nil {
+		room.Version--
+		return err
+	}
+	if result.MatchedCount == 0 {
+		room.Version--
+		return ErrorConcurrentUpdate
+	}
+	return nil
+}
+
+func (s *MongoRoomStore) Delete(id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+	defer cancel()
+
+	_, err := s.collection.DeleteOne(ctx, bson.M{"_id": id})
+	return err
+}
+
+func (s *MongoRoomStore) ListExpired(cutoff time.Time) ([]*Room, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+	defer cancel()
+
+	cursor, err := s.collection.Find(ctx, bson.M{"updatedat": bson.M{"$lt": cutoff}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var expired []*Room
+	for cursor.Next(ctx) {
+		var room Room
+		if err := cursor.Decode(&room); err != nil {
+			return nil, err
+		}
+		expired = append(expired, &room)
+	}
+	return expired, cursor.Err()
+}
@@ -0,0 +1,214 @@
+// stream.go adds a live-update subscription endpoint so clients can watch a
+// Room change (new voters, tally, Winner) without polling GetHandler.
+//
+// The subscriber registry lives on API, not Room: RoomStore.Get is free to
+// hand back a different *Room value per call (MongoRoomStore decodes a
+// fresh one every time), so a channel map on Room itself would never be
+// the one Vote/End's broadcast() sees. Keying the registry by room ID on
+// API keeps registration and broadcast looking at the same map regardless
+// of which Room value the store returned - at the cost of only reaching
+// subscribers connected to this particular instance.
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// keepaliveInterval is how often idle subscribers are pinged so
+// intermediaries (and clients) don't time the connection out.
+const keepaliveInterval = 30 * time.Second
+
+// subscriberBuffer bounds how many snapshots a slow subscriber can fall
+// behind; further snapshots to it are skipped instead of blocking Vote/End.
+const subscriberBuffer = 4
+
+var upgrader = websocket.Upgrader{
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// subscribe registers a new snapshot channel for room id.
+func (api *API) subscribe(id string) chan []byte {
+	ch := make(chan []byte, subscriberBuffer)
+
+	api.subscribersMu.Lock()
+	if api.subscribers[id] == nil {
+		api.subscribers[id] = make(map[chan []byte]struct{})
+	}
+	api.subscribers[id][ch] = struct{}{}
+	api.subscribersMu.Unlock()
+
+	return ch
+}
+
+// unsubscribe removes and closes a previously subscribed channel.
+func (api *API) unsubscribe(id string, ch chan []byte) {
+	api.subscribersMu.Lock()
+	if subs, ok := api.subscribers[id]; ok {
+		if _, ok := subs[ch]; ok {
+			delete(subs, ch)
+			close(ch)
+		}
+		if len(subs) == 0 {
+			delete(api.subscribers, id)
+		}
+	}
+	api.subscribersMu.Unlock()
+}
+
+// broadcast sends a sanitized snapshot of room to every subscriber
+// currently registered for it on this instance. Non-blocking: a subscriber
+// that isn't keeping up is skipped rather than stalling Vote/End. Callers
+// must hold room.Lock().
+func (api *API) broadcast(room *Room) {
+	api.subscribersMu.Lock()
+	channels := make([]chan []byte, 0, len(api.subscribers[room.ID]))
+	for ch := range api.subscribers[room.ID] {
+		channels = append(channels, ch)
+	}
+	api.subscribersMu.Unlock()
+	if len(channels) == 0 {
+		return
+	}
+
+	body, err := json.Marshal(room.sanitized())
+	if err != nil {
+		return
+	}
+
+	for _, ch := range channels {
+		select {
+		case ch <- body:
+		default:
+		}
+	}
+}
+
+// snapshot fetches room by id and marshals its current sanitized state, so
+// a new subscriber can be caught up immediately instead of waiting for the
+// next broadcast (which may never come if voting has stalled).
+func (api *API) snapshot(id string) ([]byte, error) {
+	room, err := api.Store.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	room.Lock()
+	defer room.Unlock()
+	return json.Marshal(room.sanitized())
+}
+
+// Subscribe Handler
+// Streams Room snapshots over SSE or WebSocket, depending on the request.
+func (api *API) SubscribeHandler(res http.ResponseWriter, req *http.Request) {
+	id := req.URL.Query().Get("id")
+	initial, err := api.snapshot(id)
+	if err != nil {
+		api.sendJSON(res, req, nil, err)
+		return
+	}
+
+	if websocket.IsWebSocketUpgrade(req) {
+		api.subscribeWebSocket(res, req, id, initial)
+		return
+	}
+	api.subscribeSSE(res, req, id, initial)
+}
+
+func (api *API) subscribeSSE(res http.ResponseWriter, req *http.Request, id string, initial []byte) {
+	flusher, ok := res.(http.Flusher)
+	if !ok {
+		http.Error(res, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	res.Header().Set("Access-Control-Allow-Origin", "*")
+	res.Header().Set("Content-Type", "text/event-stream")
+	res.Header().Set("Cache-Control", "no-cache")
+	res.Header().Set("Connection", "keep-alive")
+	res.WriteHeader(http.StatusOK)
+
+	ch := api.subscribe(id)
+	defer api.unsubscribe(id, ch)
+
+	if _, err := res.Write([]byte("data: " + string(initial) + "\n\n")); err != nil {
+		return
+	}
+	flusher.Flush()
+
+	keepalive := time.NewTicker(keepaliveInterval)
+	defer keepalive.Stop()
+
+	for {
+		select {
+		case <-req.Context().Done():
+			return
+		case <-keepalive.C:
+			if _, err := res.Write([]byte(": keepalive\n\n")); err != nil {
+				return
+			}
+			flusher.Flush()
+		case body, ok := <-ch:
+			if !ok {
+				return
+			}
+			if _, err := res.Write([]byte("data: " + string(body) + "\n\n")); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func (api *API) subscribeWebSocket(res http.ResponseWriter, req *http.Request, id string, initial []byte) {
+	conn, err := upgrader.Upgrade(res, req, nil)
+	if err != nil {
+		log.Printf("SUBSCRIBE upgrade error: %s\n", err)
+		return
+	}
+	defer conn.Close()
+
+	ch := api.subscribe(id)
+	defer api.unsubscribe(id, ch)
+
+	if err := conn.WriteMessage(websocket.TextMessage, initial); err != nil {
+		return
+	}
+
+	// Discard anything the client sends so pong/close control frames are
+	// still processed; also doubles as disconnect detection.
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	keepalive := time.NewTicker(keepaliveInterval)
+	defer keepalive.Stop()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case <-keepalive.C:
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		case body, ok := <-ch:
+			if !ok {
+				return
+			}
+			if err := conn.WriteMessage(websocket.TextMessage, body); err != nil {
+				return
+			}
+		}
+	}
+}
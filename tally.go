@@ -0,0 +1,304 @@
+// tally.go determines a room's winning Category once voting closes.
+// Tallier is pluggable per room (VotingMode, set at New()) so different
+// rooms can use plurality, instant-runoff, or score voting without End()
+// knowing which.
+package main
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// VotingMode selects how a room's ballots are tallied.
+type VotingMode string
+
+const (
+	// VotingModePlurality: one vote per voter, most votes wins. Ballot is
+	// a single category, e.g. "pizza".
+	VotingModePlurality VotingMode = "plurality"
+
+	// VotingModeInstantRunoff: voters rank choices in order of preference,
+	// lowest first-preference is eliminated each round until one has a
+	// majority. Ballot is a ranked list, e.g. "pizza,sushi,tacos"; a ballot
+	// may rank only some of the choices.
+	VotingModeInstantRunoff VotingMode = "irv"
+
+	// VotingModeScore: voters assign choices a score, highest total wins.
+	// Ballot is a list of category:score pairs, e.g. "pizza:5,sushi:3"; a
+	// choice left off the ballot scores nothing from it.
+	VotingModeScore VotingMode = "score"
+)
+
+// validVotingMode reports whether mode is one New() will accept.
+func validVotingMode(mode VotingMode) bool {
+	switch mode {
+	case VotingModePlurality, VotingModeInstantRunoff, VotingModeScore:
+		return true
+	default:
+		return false
+	}
+}
+
+// Tallier ranks a room's choices from most to least preferred once voting
+// closes. End() walks the ranking and falls through to the next choice if
+// PlaceAPI.Get can't find a place for an earlier one.
+type Tallier interface {
+	Tally(room *Room) []Category
+}
+
+// tallierFor returns the Tallier for mode, defaulting to plurality for an
+// empty or unrecognized mode (e.g. rooms created before VotingMode existed).
+func tallierFor(mode VotingMode) Tallier {
+	switch mode {
+	case VotingModeInstantRunoff:
+		return InstantRunoffTallier{}
+	case VotingModeScore:
+		return ScoreTallier{}
+	default:
+		return PluralityTallier{}
+	}
+}
+
+// PluralityTallier ranks choices by raw vote count, most votes first.
+type PluralityTallier struct{}
+
+func (PluralityTallier) Tally(room *Room) []Category {
+	ranked := make([]Category, 0, len(room.Votes))
+	for k := range room.Votes {
+		ranked = append(ranked, Category(k))
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		vi, vj := room.Votes[string(ranked[i])], room.Votes[string(ranked[j])]
+		if vi != vj {
+			return vi > vj
+		}
+		return ranked[i] < ranked[j]
+	})
+	return ranked
+}
+
+// ScoreTallier ranks choices by the sum of scores voters assigned them,
+// highest total first.
+type ScoreTallier struct{}
+
+func (ScoreTallier) Tally(room *Room) []Category {
+	totals := make(map[Category]int, len(room.Choices))
+	for _, c := range room.Choices {
+		totals[Category(c)] = 0
+	}
+	for _, raw := range room.Ballots {
+		for cat, score := range parseScoreBallot(raw) {
+			totals[cat] += score
+		}
+	}
+
+	ranked := make([]Category, 0, len(totals))
+	for cat := range totals {
+		ranked = append(ranked, cat)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if totals[ranked[i]] != totals[ranked[j]] {
+			return totals[ranked[i]] > totals[ranked[j]]
+		}
+		return ranked[i] < ranked[j]
+	})
+	return ranked
+}
+
+// InstantRunoffTallier repeatedly eliminates the choice with the fewest
+// first-preference votes, redistributing its ballots to their next
+// preference, until one choice has a majority.
+type InstantRunoffTallier struct{}
+
+func (InstantRunoffTallier) Tally(room *Room) []Category {
+	ballots := make([][]Category, 0, len(room.Ballots))
+	for _, raw := range room.Ballots {
+		ballots = append(ballots, parseRankedBallot(raw))
+	}
+
+	remaining := make(map[Category]bool, len(room.Choices))
+	for _, c := range room.Choices {
+		remaining[Category(c)] = true
+	}
+
+	// eliminated records choices in the order they were knocked out,
+	// earliest-eliminated first; the final ranking reverses this.
+	var eliminated []Category
+
+	for len(remaining) > 1 {
+		counts := firstPreferenceCounts(ballots, remaining)
+
+		total := 0
+		for _, n := range counts {
+			total += n
+		}
+		if winner, ok := majorityWinner(counts, total); ok {
+			return rankFromRunoff(winner, remaining, counts, eliminated)
+		}
+
+		loser := lowestFirstPreference(counts)
+		eliminated = append(eliminated, loser)
+		delete(remaining, loser)
+	}
+
+	var winner Category
+	for cat := range remaining {
+		winner = cat
+	}
+	return rankFromRunoff(winner, remaining, nil, eliminated)
+}
+
+// rankFromRunoff builds the final ranked list: winner, then any other
+// choices still standing when the majority was reached (most
+// first-preference votes first), then everyone eliminated, most-recently
+// eliminated first.
+func rankFromRunoff(winner Category, remaining map[Category]bool, counts map[Category]int, eliminated []Category) []Category {
+	runnersUp := make([]Category, 0, len(remaining))
+	for cat := range remaining {
+		if cat != winner {
+			runnersUp = append(runnersUp, cat)
+		}
+	}
+	sort.Slice(runnersUp, func(i, j int) bool {
+		if counts[runnersUp[i]] != counts[runnersUp[j]] {
+			return counts[runnersUp[i]] > counts[runnersUp[j]]
+		}
+		return runnersUp[i] < runnersUp[j]
+	})
+
+	ranked := append([]Category{winner}, runnersUp...)
+	for i := len(eliminated) - 1; i >= 0; i-- {
+		ranked = append(ranked, eliminated[i])
+	}
+	return ranked
+}
+
+// firstPreferenceCounts tallies each remaining choice's first preference
+// across ballots, skipping any ranked choice that's already eliminated.
+func firstPreferenceCounts(ballots [][]Category, remaining map[Category]bool) map[Category]int {
+	counts := make(map[Category]int, len(remaining))
+	for cat := range remaining {
+		counts[cat] = 0
+	}
+	for _, ballot := range ballots {
+		for _, choice := range ballot {
+			if remaining[choice] {
+				counts[choice]++
+				break
+			}
+		}
+	}
+	return counts
+}
+
+// majorityWinner returns the choice with more than half of total, if any.
+func majorityWinner(counts map[Category]int, total int) (Category, bool) {
+	if total == 0 {
+		return "", false
+	}
+	for cat, n := range counts {
+		if n*2 > total {
+			return cat, true
+		}
+	}
+	return "", false
+}
+
+// lowestFirstPreference returns the choice with the fewest first-preference
+// votes, breaking ties alphabetically for determinism.
+func lowestFirstPreference(counts map[Category]int) Category {
+	cats := make([]Category, 0, len(counts))
+	for cat := range counts {
+		cats = append(cats, cat)
+	}
+	sort.Slice(cats, func(i, j int) bool { return cats[i] < cats[j] })
+
+	lowest := cats[0]
+	for _, cat := range cats[1:] {
+		if counts[cat] < counts[lowest] {
+			lowest = cat
+		}
+	}
+	return lowest
+}
+
+// parseRankedBallot parses an IRV ballot like "pizza,sushi,tacos" into an
+// ordered preference list.
+func parseRankedBallot(raw string) []Category {
+	parts := strings.Split(raw, ",")
+	ranked := make([]Category, 0, len(parts))
+	for _, p := range parts {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		ranked = append(ranked, Category(p))
+	}
+	return ranked
+}
+
+// parseScoreBallot parses a score ballot like "pizza:5,sushi:3" into a
+// category -> score map. Malformed pairs are skipped.
+func parseScoreBallot(raw string) map[Category]int {
+	scores := make(map[Category]int)
+	for _, pair := range strings.Split(raw, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		kv := strings.SplitN(pair, ":", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(kv[1]))
+		if err != nil {
+			continue
+		}
+		scores[Category(strings.TrimSpace(kv[0]))] = n
+	}
+	return scores
+}
+
+// validateBallot checks that vote is well-formed for mode and only
+// references known choices.
+func validateBallot(mode VotingMode, vote string, choices []string) error {
+	switch mode {
+	case VotingModeInstantRunoff:
+		ranked := parseRankedBallot(vote)
+		if len(ranked) == 0 {
+			return ErrorInvalidVote
+		}
+		for _, c := range ranked {
+			if !containsChoice(choices, string(c)) {
+				return ErrorInvalidVote
+			}
+		}
+		return nil
+	case VotingModeScore:
+		scores := parseScoreBallot(vote)
+		if len(scores) == 0 {
+			return ErrorInvalidVote
+		}
+		for c := range scores {
+			if !containsChoice(choices, string(c)) {
+				return ErrorInvalidVote
+			}
+		}
+		return nil
+	default:
+		if !containsChoice(choices, vote) {
+			return ErrorInvalidVote
+		}
+		return nil
+	}
+}
+
+func containsChoice(choices []string, choice string) bool {
+	for _, c := range choices {
+		if c == choice {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPluralityTallierBreaksTiesAlphabetically(t *testing.T) {
+	room := &Room{
+		Votes: map[string]int32{
+			"sushi": 2,
+			"pizza": 2,
+			"tacos": 1,
+		},
+	}
+
+	got := PluralityTallier{}.Tally(room)
+	want := []Category{"pizza", "sushi", "tacos"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Tally() = %v, want %v", got, want)
+	}
+}
+
+func TestScoreTallierSumsAndBreaksTies(t *testing.T) {
+	room := &Room{
+		Choices: []string{"pizza", "sushi", "tacos"},
+		Ballots: map[string]string{
+			"a": "pizza:5,sushi:3,tacos:1",
+			"b": "pizza:1,sushi:3,tacos:5",
+		},
+	}
+
+	got := ScoreTallier{}.Tally(room)
+	// pizza=6, sushi=6, tacos=6 - a clean three-way tie, alphabetical.
+	want := []Category{"pizza", "sushi", "tacos"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Tally() = %v, want %v", got, want)
+	}
+}
+
+func TestInstantRunoffTallierMajorityOnFirstRound(t *testing.T) {
+	room := &Room{
+		Choices: []string{"pizza", "sushi", "tacos"},
+		Ballots: map[string]string{
+			"a": "pizza,sushi,tacos",
+			"b": "pizza,tacos,sushi",
+			"c": "sushi,pizza,tacos",
+		},
+	}
+
+	got := InstantRunoffTallier{}.Tally(room)
+	if got[0] != "pizza" {
+		t.Fatalf("Tally()[0] = %v, want pizza", got[0])
+	}
+}
+
+func TestInstantRunoffTallierEliminatesAndRedistributes(t *testing.T) {
+	room := &Room{
+		Choices: []string{"pizza", "sushi", "tacos"},
+		Ballots: map[string]string{
+			"a": "tacos,pizza,sushi",
+			"b": "sushi,pizza,tacos",
+			"c": "pizza,sushi,tacos",
+			"d": "pizza,tacos,sushi",
+		},
+	}
+
+	// First preferences: pizza=2, sushi=1, tacos=1. No majority of 4, so
+	// the lowest is eliminated - sushi and tacos tie at 1, alphabetically
+	// sushi loses first. Its ballot's next preference (pizza) goes to
+	// pizza, giving pizza a 3/4 majority.
+	got := InstantRunoffTallier{}.Tally(room)
+	want := []Category{"pizza", "tacos", "sushi"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Tally() = %v, want %v", got, want)
+	}
+}
+
+func TestInstantRunoffTallierExhaustedBallotsStillResolve(t *testing.T) {
+	room := &Room{
+		Choices: []string{"pizza", "sushi", "tacos"},
+		Ballots: map[string]string{
+			// "a" only ranks tacos, so once tacos is eliminated its ballot
+			// is exhausted and no longer counts toward anyone.
+			"a": "tacos",
+			"b": "pizza,sushi",
+			"c": "sushi,pizza",
+			"d": "pizza,sushi",
+			"e": "sushi,pizza",
+			"f": "pizza,sushi",
+		},
+	}
+
+	// First preferences: pizza=3, sushi=2, tacos=1 - no majority of 6, so
+	// tacos is eliminated. "a" is then exhausted, leaving pizza 3 of 5.
+
+	got := InstantRunoffTallier{}.Tally(room)
+	if got[0] != "pizza" {
+		t.Fatalf("Tally()[0] = %v, want pizza", got[0])
+	}
+}
+
+func TestValidateBallot(t *testing.T) {
+	choices := []string{"pizza", "sushi", "tacos"}
+
+	tests := []struct {
+		name    string
+		mode    VotingMode
+		vote    string
+		wantErr bool
+	}{
+		{"plurality valid", VotingModePlurality, "pizza", false},
+		{"plurality unknown choice", VotingModePlurality, "ramen", true},
+		{"irv valid", VotingModeInstantRunoff, "pizza,sushi,tacos", false},
+		{"irv empty", VotingModeInstantRunoff, "", true},
+		{"irv unknown choice", VotingModeInstantRunoff, "pizza,ramen", true},
+		{"score valid", VotingModeScore, "pizza:5,sushi:1", false},
+		{"score malformed skipped to empty", VotingModeScore, "pizza", true},
+		{"score unknown choice", VotingModeScore, "ramen:5", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateBallot(tt.mode, tt.vote, choices)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validateBallot(%q, %q) error = %v, wantErr %v", tt.mode, tt.vote, err, tt.wantErr)
+			}
+		})
+	}
+}